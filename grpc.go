@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// grpcServiceName is the fully qualified service synthesized for every protocol marked with
+// the "grpc" transport. There is only ever one service: each Protocol.Path becomes one RPC on it.
+const grpcServiceName = "dynamicadaptor.DynamicService"
+
+// newGRPCServer builds a grpc.Server whose methods are synthesized at startup, one per protocol,
+// from the same Config that drives the HTTP mux. Requests and responses are carried as
+// google.protobuf.Struct so no .proto file or generated stub is required.
+func newGRPCServer(protocols []*Protocol) *grpc.Server {
+	server := grpc.NewServer()
+
+	desc := &grpc.ServiceDesc{
+		ServiceName: grpcServiceName,
+		HandlerType: (*interface{})(nil),
+		Metadata:    "dynamicadaptor.proto",
+	}
+	for _, protocol := range protocols {
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: grpcMethodName(protocol.Path),
+			Handler:    newGRPCHandler(protocol),
+		})
+	}
+
+	server.RegisterService(desc, nil)
+	return server
+}
+
+// serveGRPC listens on addr and blocks serving the given grpc.Server.
+func serveGRPC(server *grpc.Server, addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("grpc listen on %s: %w", addr, err)
+	}
+	log.Println("grpc listening on", addr)
+	return server.Serve(lis)
+}
+
+// newGRPCHandler adapts a Protocol into a grpc.MethodHandler. Argument validation runs through
+// validateArgs, the exact function the HTTP entry point uses, so a single config drives both
+// surfaces identically.
+func newGRPCHandler(protocol *Protocol) func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		in := &structpb.Struct{}
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+
+		handle := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return handleGRPCRequest(protocol, req.(*structpb.Struct))
+		}
+		if interceptor == nil {
+			return handle(ctx, in)
+		}
+		info := &grpc.UnaryServerInfo{
+			Server:     srv,
+			FullMethod: fmt.Sprintf("/%s/%s", grpcServiceName, grpcMethodName(protocol.Path)),
+		}
+		return interceptor(ctx, in, info, handle)
+	}
+}
+
+func handleGRPCRequest(protocol *Protocol, in *structpb.Struct) (*structpb.Struct, error) {
+	start := time.Now()
+	defer func() {
+		statistic.Observe(fmt.Sprintf("%s.latency_ms", protocol.Path), float64(time.Since(start).Microseconds())/1000)
+	}()
+	statistic.Inc(fmt.Sprintf("%s.request", protocol.Path), 1)
+
+	data := normalizeStructMap(in.AsMap())
+	valid, invalid := validateArgs(protocol.Args, protocol.validFuncs, protocol.crossFieldRules, data, func(stat string, delta int) {
+		statistic.Inc(fmt.Sprintf("%s.%s", protocol.Path, stat), delta)
+	})
+
+	out, err := structpb.NewStruct(map[string]interface{}{
+		"code":    Code_OK,
+		"valid":   denormalizeStructValue(map[string]interface{}(valid)),
+		"invalid": map[string]interface{}(invalid),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode response: %w", err)
+	}
+	return out, nil
+}
+
+// normalizeStructMap converts every float64 a structpb.Struct decodes numbers into back into
+// json.Number, recursing through nested objects/arrays, so gRPC requests land on exactly the same
+// validFunc code path (generateIntValidFunc et al. type-assert v.(json.Number)) as HTTP requests
+// decoded with json.Decoder.UseNumber.
+func normalizeStructMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = normalizeStructValue(v)
+	}
+	return out
+}
+
+func normalizeStructValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case float64:
+		return json.Number(strconv.FormatFloat(val, 'f', -1, 64))
+	case map[string]interface{}:
+		return normalizeStructMap(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = normalizeStructValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// denormalizeStructValue reverses normalizeStructValue's json.Number conversion, recursing through
+// nested objects/arrays, so a response built from validated args (which may echo json.Number back
+// out of valid) round-trips through structpb.NewStruct, which rejects json.Number outright.
+func denormalizeStructValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		if f, err := val.Float64(); err == nil {
+			return f
+		}
+		return val.String()
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = denormalizeStructValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = denormalizeStructValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// grpcMethodName turns a protocol path such as "/user/create" into a CamelCase RPC name like
+// "UserCreate", the same way a .proto author would name a synthesized rpc.
+func grpcMethodName(path string) string {
+	parts := strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '_' || r == '-'
+	})
+	var b strings.Builder
+	for _, part := range parts {
+		r := []rune(part)
+		if len(r) == 0 {
+			continue
+		}
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	if b.Len() == 0 {
+		return "Root"
+	}
+	return b.String()
+}
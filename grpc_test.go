@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// TestHandleGRPCRequestIntArg drives handleGRPCRequest with a numeric arg the way a real gRPC
+// client would (structpb decodes JSON numbers as float64), guarding against the Int validator
+// rejecting every numeric arg and against the response failing to re-encode as a structpb.Struct.
+func TestHandleGRPCRequestIntArg(t *testing.T) {
+	protocol := &Protocol{
+		Path: "/echo",
+		Args: []*Arg{
+			{Name: "count", Type: Int, Required: true},
+		},
+	}
+	validFuncs, err := generateValidFuncs(protocol.Args)
+	if err != nil {
+		t.Fatalf("generateValidFuncs: %v", err)
+	}
+	protocol.validFuncs = validFuncs
+
+	in, err := structpb.NewStruct(map[string]interface{}{"count": float64(42)})
+	if err != nil {
+		t.Fatalf("structpb.NewStruct(in): %v", err)
+	}
+
+	out, err := handleGRPCRequest(protocol, in)
+	if err != nil {
+		t.Fatalf("handleGRPCRequest: %v", err)
+	}
+
+	got := out.AsMap()
+	invalid, _ := got["invalid"].(map[string]interface{})
+	if len(invalid) != 0 {
+		t.Fatalf("count rejected as invalid: %v", invalid)
+	}
+	valid, _ := got["valid"].(map[string]interface{})
+	if valid["count"] != float64(42) {
+		t.Errorf("valid[count] = %v, want 42", valid["count"])
+	}
+}
@@ -6,50 +6,65 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 	"sync"
+	"time"
 )
 
 func main() {
-	var addr, confFile string
+	var addr, grpcAddr, confFile string
 
 	flag.StringVar(&addr, "addr", ":8080", "listen addr")
+	flag.StringVar(&grpcAddr, "grpc-addr", ":9090", "grpc listen addr, only used when config contains a grpc transport")
 	flag.StringVar(&confFile, "conf", "config.json", "config file path")
 	flag.Parse()
 
-	f, err := os.OpenFile(confFile, os.O_RDONLY, os.ModePerm)
-	if err != nil {
-		log.Fatalln("open config file", err.Error())
+	reloader := NewReloader(confFile)
+	if _, err := reloader.Reload(false); err != nil {
+		log.Fatalln("load config file failed", err.Error())
 	}
-	defer f.Close()
 
-	var conf Config
-	decoder := json.NewDecoder(f)
-	decoder.UseNumber()
-	err = decoder.Decode(&conf)
+	watchSIGHUP(reloader)
+
+	conf, err := loadConfig(confFile)
 	if err != nil {
-		log.Fatalln("decode config file failed", err.Error())
+		log.Fatalln("load config file failed", err.Error())
+	}
+	if grpcProtocols := grpcProtocolsOf(conf); len(grpcProtocols) > 0 {
+		grpcServer := newGRPCServer(grpcProtocols)
+		go func() {
+			if err := serveGRPC(grpcServer, grpcAddr); err != nil {
+				log.Fatalln("serve grpc failed", err.Error())
+			}
+		}()
 	}
-	f.Close()
 
-	http.HandleFunc("/statistics", statisticHandler)
+	if err := http.ListenAndServe(addr, reloader); err != nil {
+		log.Fatalln(err)
+	}
+}
 
+// grpcProtocolsOf prepares the gRPC side of conf the same way Reloader prepares the HTTP side,
+// since the gRPC server is built once at startup and is not part of the hot-reload surface.
+func grpcProtocolsOf(conf *Config) []*Protocol {
+	var grpcProtocols []*Protocol
 	for _, protocol := range conf.Protocols {
-		log.Println("add", protocol.Method, protocol.Path)
-		validFuncs := map[string]validFunc{}
-		for _, arg := range protocol.Args {
-			validFuncs[arg.Name], err = generateValidFunc(arg)
-			if err != nil {
-				log.Println("generate arg valid failed", arg.Name, err)
-			}
+		validFuncs, err := generateValidFuncs(protocol.Args)
+		if err != nil {
+			log.Fatalln("generate arg valid failed", protocol.Path, err)
 		}
-		http.HandleFunc(protocol.Path, newHandleFunc(protocol.Method, protocol.Args, validFuncs))
-	}
+		protocol.validFuncs = validFuncs
 
-	if err = http.ListenAndServe(addr, nil); err != nil {
-		log.Fatalln(err)
+		protocol.crossFieldRules, err = buildCrossFieldRules(protocol.Args)
+		if err != nil {
+			log.Fatalln("build cross field rules failed", protocol.Path, err)
+		}
+
+		if protocol.servesGRPC() {
+			grpcProtocols = append(grpcProtocols, protocol)
+		}
 	}
+	return grpcProtocols
 }
 
 var statistic = &Statistic{}
@@ -60,8 +75,12 @@ func statisticHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, statistic.Json())
 }
 
-func newHandleFunc(method string, args []*Arg, validFuncs map[string]validFunc) func(w http.ResponseWriter, r *http.Request) {
+func newHandleFunc(method string, args []*Arg, validFuncs map[string]validFunc, crossFieldRules []CrossFieldRule) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		defer func() {
+			statistic.Observe(fmt.Sprintf("%s.latency_ms", r.RequestURI), float64(time.Since(start).Microseconds())/1000)
+		}()
 		statistic.Inc(fmt.Sprintf("%s.request", r.RequestURI), 1)
 		statistic.Inc(fmt.Sprintf("%s.total_size", r.RequestURI), int(r.ContentLength))
 		if method != r.Method {
@@ -85,29 +104,9 @@ func newHandleFunc(method string, args []*Arg, validFuncs map[string]validFunc)
 			return
 		}
 
-		valid := m{}
-		invalid := m{}
-		for _, arg := range args {
-			v, ok := data[arg.Name]
-			if !ok {
-				invalid[arg.Name] = "<missed>"
-				statistic.Inc(fmt.Sprintf("%s.args.missed", r.RequestURI), 1)
-			} else {
-				validFunc := validFuncs[arg.Name]
-				if validFunc != nil {
-					if err := validFunc(v); err != nil {
-						invalid[arg.Name] = fmt.Sprintf("<err: %s>", err.Error())
-						statistic.Inc(fmt.Sprintf("%s.args.invalid", r.RequestURI), 1)
-					} else {
-						statistic.Inc(fmt.Sprintf("%s.args.valid", r.RequestURI), 1)
-						valid[arg.Name] = v
-					}
-				} else {
-					valid[arg.Name] = v
-					statistic.Inc(fmt.Sprintf("%s.args.valid", r.RequestURI), 1)
-				}
-			}
-		}
+		valid, invalid := validateArgs(args, validFuncs, crossFieldRules, data, func(stat string, delta int) {
+			statistic.Inc(fmt.Sprintf("%s.%s", r.RequestURI, stat), delta)
+		})
 		m{
 			"code":    Code_OK,
 			"valid":   valid,
@@ -116,6 +115,59 @@ func newHandleFunc(method string, args []*Arg, validFuncs map[string]validFunc)
 	}
 }
 
+// validateArgs runs the shared validation pipeline produced by generateValidFunc and
+// buildCrossFieldRules against data, reporting per-arg outcomes through onStat so both the HTTP
+// and gRPC entry points observe the same statistics. It is the single place argument validation
+// happens, regardless of transport.
+func validateArgs(args []*Arg, validFuncs map[string]validFunc, crossFieldRules []CrossFieldRule, data map[string]interface{}, onStat func(stat string, delta int)) (valid, invalid m) {
+	valid = m{}
+	invalid = m{}
+	for _, arg := range args {
+		v, ok := data[arg.Name]
+		if !ok {
+			if arg.Required {
+				invalid[arg.Name] = "<missed>"
+				onStat("args.missed", 1)
+			}
+			continue
+		}
+
+		if arg.Type == Object || arg.Type == Array {
+			if errs := validateNested(arg, v, "/"+arg.Name); len(errs) > 0 {
+				for _, fe := range errs {
+					invalid[fe.Pointer] = fmt.Sprintf("<err: %s>", fe.Message)
+				}
+				onStat("args.invalid", 1)
+			} else {
+				valid[arg.Name] = v
+				onStat("args.valid", 1)
+			}
+			continue
+		}
+
+		validFunc := validFuncs[arg.Name]
+		if validFunc != nil {
+			if err := validFunc(v); err != nil {
+				invalid[arg.Name] = fmt.Sprintf("<err: %s>", err.Error())
+				onStat("args.invalid", 1)
+			} else {
+				onStat("args.valid", 1)
+				valid[arg.Name] = v
+			}
+		} else {
+			valid[arg.Name] = v
+			onStat("args.valid", 1)
+		}
+	}
+	for _, rule := range crossFieldRules {
+		if err := rule.Check(data); err != nil {
+			invalid[rule.Arg] = fmt.Sprintf("<err: %s>", err.Error())
+			onStat("args.invalid", 1)
+		}
+	}
+	return valid, invalid
+}
+
 const (
 	Code_OK           = 0
 	Code_Err_Request  = 10000
@@ -139,6 +191,36 @@ type Protocol struct {
 	Path   string
 	Method string
 	Args   []*Arg
+	//Transport is a comma separated list of "http"/"grpc", defaulting to "http" when empty.
+	Transport string
+
+	validFuncs      map[string]validFunc
+	crossFieldRules []CrossFieldRule
+}
+
+func (p *Protocol) transports() []string {
+	if p.Transport == "" {
+		return []string{"http"}
+	}
+	return strings.Split(p.Transport, ",")
+}
+
+func (p *Protocol) servesHTTP() bool {
+	for _, t := range p.transports() {
+		if t == "http" {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Protocol) servesGRPC() bool {
+	for _, t := range p.transports() {
+		if t == "grpc" {
+			return true
+		}
+	}
+	return false
 }
 
 type ArgType int
@@ -150,12 +232,25 @@ const (
 	String ArgType = 2
 	//Bool
 	Bool ArgType = 3
+	//Object is a nested set of named Properties, each an *Arg in its own right.
+	Object ArgType = 4
+	//Array is a homogeneous list described by Items, an *Arg shared by every element.
+	Array ArgType = 5
 )
 
 type Arg struct {
 	Name         string
 	Type         ArgType
 	Restrictions Restrictions
+	//Required controls whether a missing arg is reported as "<missed>"; optional args are
+	//simply omitted from both the valid and invalid maps when absent.
+	Required bool
+	//Items describes every element of an Array arg. Ignored for other types.
+	Items *Arg
+	//Properties describes the named fields of an Object arg. Ignored for other types.
+	Properties []*Arg
+
+	validFunc validFunc
 }
 
 type Restrictions map[string]interface{}
@@ -169,9 +264,30 @@ func generateValidFunc(arg *Arg) (validFunc, error) {
 	case Bool:
 		return generateBoolValidFunc(arg)
 	default:
-		log.Println("arg", arg.Name, "argType", arg.Type, "currently not supported")
+		return nil, fmt.Errorf("arg %s: argType %d is not supported", arg.Name, arg.Type)
 	}
-	return nil, nil
+}
+
+// generateValidFuncs builds the validFunc for every scalar arg and compiles the nested validators
+// for every Object/Array arg (see compileArg), the single place both the HTTP mux (via Reloader)
+// and the gRPC server derive their validators from a loaded config.
+func generateValidFuncs(args []*Arg) (map[string]validFunc, error) {
+	validFuncs := map[string]validFunc{}
+	for _, arg := range args {
+		if arg.Type == Object || arg.Type == Array {
+			if err := compileArg(arg); err != nil {
+				return nil, fmt.Errorf("arg %s: %w", arg.Name, err)
+			}
+			continue
+		}
+		validFunc, err := generateValidFunc(arg)
+		if err != nil {
+			return nil, fmt.Errorf("arg %s: %w", arg.Name, err)
+		}
+		arg.validFunc = validFunc
+		validFuncs[arg.Name] = validFunc
+	}
+	return validFuncs, nil
 }
 
 type validFunc func(v interface{}) error
@@ -233,45 +349,66 @@ func newBoolTypeValidFunc(validFuncs ...boolValidFunc) validFunc {
 }
 
 func generateStringValidFunc(arg *Arg) (validFunc, error) {
-	stringValidFuncs := []stringValidFunc{}
-	for name, restriction := range arg.Restrictions {
-		switch name {
-		case "length":
-			if validFunc, err := generateStringLengthValidFunc(restriction); err != nil {
-				return nil, err
-			} else if validFunc != nil {
-				stringValidFuncs = append(stringValidFuncs, validFunc)
-			} else {
-				log.Println("valid func for", arg.Name, "of length can not be applied, may max and min not found")
-			}
-		default:
-			log.Println("arg", arg.Name, "restriction of", name, "currently not suppored")
-		}
+	funcs := []validFunc{newStringTypeValidFunc()}
+	restricted, err := generateRegisteredValidFuncs(arg)
+	if err != nil {
+		return nil, err
 	}
-	return newStringTypeValidFunc(stringValidFuncs...), nil
+	return combineValidFuncs(append(funcs, restricted...)...), nil
 }
 
 func generateIntValidFunc(arg *Arg) (validFunc, error) {
-	intValidFuncs := []intValidFunc{}
+	funcs := []validFunc{newIntTypeValidFunc()}
+	restricted, err := generateRegisteredValidFuncs(arg)
+	if err != nil {
+		return nil, err
+	}
+	return combineValidFuncs(append(funcs, restricted...)...), nil
+}
+
+func generateBoolValidFunc(arg *Arg) (validFunc, error) {
+	restricted, err := generateRegisteredValidFuncs(arg)
+	if err != nil {
+		return nil, err
+	}
+	return combineValidFuncs(append([]validFunc{newBoolTypeValidFunc()}, restricted...)...), nil
+}
+
+// generateRegisteredValidFuncs looks every restriction on arg up in DefaultRegistry, building one
+// validFunc per restriction. "requires" and "excludes" are cross-argument and handled separately
+// by buildCrossFieldRules, so their factories intentionally contribute no per-arg validFunc.
+func generateRegisteredValidFuncs(arg *Arg) ([]validFunc, error) {
+	var funcs []validFunc
 	for name, restriction := range arg.Restrictions {
-		switch name {
-		case "range":
-			if validFunc, err := generateIntRangeValidFunc(restriction); err != nil {
-				return nil, err
-			} else if validFunc != nil {
-				intValidFuncs = append(intValidFuncs, validFunc)
-			} else {
-				log.Println("valid func for", arg.Name, "of length can not be applied, may max and min not found")
-			}
-		default:
-			log.Println("arg", arg.Name, "restriction of", name, "currently not suppored")
+		factory, ok := DefaultRegistry.Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("arg %s: restriction %q is not registered", arg.Name, name)
+		}
+		validFunc, err := factory(arg.Type, restriction)
+		if err != nil {
+			return nil, fmt.Errorf("arg %s: restriction %q: %w", arg.Name, name, err)
+		}
+		if validFunc != nil {
+			funcs = append(funcs, validFunc)
 		}
 	}
-	return newIntTypeValidFunc(intValidFuncs...), nil
+	return funcs, nil
 }
 
-func generateBoolValidFunc(arg *Arg) (validFunc, error) {
-	return newBoolTypeValidFunc(), nil
+// combineValidFuncs chains validFuncs so the first failure wins, matching the short-circuit
+// behaviour the per-type valid funcs already had.
+func combineValidFuncs(funcs ...validFunc) validFunc {
+	return func(v interface{}) error {
+		for _, f := range funcs {
+			if f == nil {
+				continue
+			}
+			if err := f(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 }
 
 //string length
@@ -380,6 +517,25 @@ func generateIntRangeValidFunc(restriction interface{}) (intValidFunc, error) {
 type Statistic struct {
 	data  map[string]interface{}
 	mutex sync.Mutex
+
+	quantiles   map[string]*Quantile
+	quantileMux sync.Mutex
+}
+
+//Observe records a single latency sample (in milliseconds) at path, e.g. "/echo.latency_ms".
+func (s *Statistic) Observe(path string, ms float64) {
+	s.quantileMux.Lock()
+	if s.quantiles == nil {
+		s.quantiles = map[string]*Quantile{}
+	}
+	q, ok := s.quantiles[path]
+	if !ok {
+		q = NewQuantile(defaultLatencyTargets)
+		s.quantiles[path] = q
+	}
+	s.quantileMux.Unlock()
+
+	q.Insert(ms)
 }
 
 func (s *Statistic) Inc(path string, delta int) error {
@@ -433,11 +589,59 @@ func (s *Statistic) Inc(path string, delta int) error {
 
 func (s *Statistic) Json() string {
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	merged := cloneStatsMap(s.data)
+	s.mutex.Unlock()
+
+	s.quantileMux.Lock()
+	for path, q := range s.quantiles {
+		parts := strings.Split(path, ".")
+		pathParts := parts[:len(parts)-1]
+		valueName := parts[len(parts)-1]
+		cur, err := navigateStatsMap(merged, pathParts)
+		if err != nil {
+			log.Println("merge quantile for", path, "failed", err)
+			continue
+		}
+		cur[valueName] = q.Snapshot()
+	}
+	s.quantileMux.Unlock()
 
-	bs, err := json.Marshal(s.data)
+	bs, err := json.Marshal(merged)
 	if err != nil {
 		panic(err)
 	}
 	return string(bs)
 }
+
+func cloneStatsMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if nested, ok := v.(map[string]interface{}); ok {
+			out[k] = cloneStatsMap(nested)
+		} else {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// navigateStatsMap walks (creating as needed) the nested maps named by parts, the same traversal
+// Inc does, so Json can graft quantile snapshots onto the same path a counter would live at.
+func navigateStatsMap(root map[string]interface{}, parts []string) (map[string]interface{}, error) {
+	cur := root
+	for _, part := range parts {
+		i, ok := cur[part]
+		if !ok {
+			next := map[string]interface{}{}
+			cur[part] = next
+			cur = next
+			continue
+		}
+		next, ok := i.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s is a value", part)
+		}
+		cur = next
+	}
+	return cur, nil
+}
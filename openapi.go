@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// openAPIJSONHandler serves an OpenAPI 3 document describing conf.Protocols, generated fresh on
+// every request so it always reflects the config the rest of the mux was built from.
+func openAPIJSONHandler(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(buildOpenAPIDocument(conf))
+	}
+}
+
+//openAPIYAMLHandler is the same document as openAPIJSONHandler, rendered as YAML.
+func openAPIYAMLHandler(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		fmt.Fprint(w, yamlEncode(buildOpenAPIDocument(conf), 0))
+	}
+}
+
+// buildOpenAPIDocument walks conf.Protocols and emits an OpenAPI 3.0 document: one path+operation
+// per HTTP-served protocol (gRPC-only protocols are left out), request body schema derived from
+// Args/Restrictions, and the standard
+// {code,valid,invalid} response envelope every protocol shares.
+func buildOpenAPIDocument(conf *Config) map[string]interface{} {
+	paths := map[string]interface{}{}
+	for _, protocol := range conf.Protocols {
+		if !protocol.servesHTTP() {
+			continue
+		}
+		method := strings.ToLower(protocol.Method)
+		if method == "" {
+			method = "post"
+		}
+		pathItem, _ := paths[protocol.Path].(map[string]interface{})
+		if pathItem == nil {
+			pathItem = map[string]interface{}{}
+			paths[protocol.Path] = pathItem
+		}
+		pathItem[method] = map[string]interface{}{
+			"summary": fmt.Sprintf("%s %s", protocol.Method, protocol.Path),
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": argsSchema(protocol.Args),
+					},
+				},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "dynamic adaptor response",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{"$ref": "#/components/schemas/Envelope"},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "dynamic-adaptor-demo",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Envelope": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"code":    map[string]interface{}{"type": "integer"},
+						"valid":   map[string]interface{}{"type": "object"},
+						"invalid": map[string]interface{}{"type": "object"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func argsSchema(args []*Arg) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	for _, arg := range args {
+		properties[arg.Name] = argSchema(arg)
+		if arg.Required {
+			required = append(required, arg.Name)
+		}
+	}
+	sort.Strings(required)
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func argSchema(arg *Arg) map[string]interface{} {
+	schema := map[string]interface{}{"type": argTypeSchemaType(arg.Type)}
+	switch arg.Type {
+	case Object:
+		properties := map[string]interface{}{}
+		var required []string
+		for _, prop := range arg.Properties {
+			properties[prop.Name] = argSchema(prop)
+			if prop.Required {
+				required = append(required, prop.Name)
+			}
+		}
+		schema["properties"] = properties
+		sort.Strings(required)
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		if allowed, ok := arg.Restrictions["additionalProperties"]; ok {
+			schema["additionalProperties"] = allowed
+		}
+	case Array:
+		if arg.Items != nil {
+			schema["items"] = argSchema(arg.Items)
+		}
+	}
+	for name, restriction := range arg.Restrictions {
+		applyRestrictionToSchema(schema, name, restriction)
+	}
+	return schema
+}
+
+func argTypeSchemaType(t ArgType) string {
+	switch t {
+	case Int:
+		return "integer"
+	case String:
+		return "string"
+	case Bool:
+		return "boolean"
+	case Object:
+		return "object"
+	case Array:
+		return "array"
+	default:
+		return "string"
+	}
+}
+
+// applyRestrictionToSchema maps the restriction names DefaultRegistry knows about onto the
+// matching OpenAPI/JSON-Schema keyword. Restrictions with no schema equivalent (e.g.
+// "requires"/"excludes", which are cross-argument) are left untranslated.
+func applyRestrictionToSchema(schema map[string]interface{}, name string, restriction interface{}) {
+	switch name {
+	case "length":
+		m, ok := restriction.(map[string]interface{})
+		if !ok {
+			return
+		}
+		if min, ok := m["min"]; ok {
+			schema["minLength"] = min
+		}
+		if max, ok := m["max"]; ok {
+			schema["maxLength"] = max
+		}
+	case "range":
+		m, ok := restriction.(map[string]interface{})
+		if !ok {
+			return
+		}
+		if min, ok := m["min"]; ok {
+			schema["minimum"] = min
+		}
+		if max, ok := m["max"]; ok {
+			schema["maximum"] = max
+		}
+	case "regex":
+		schema["pattern"] = restriction
+	case "enum":
+		schema["enum"] = restriction
+	case "format":
+		schema["format"] = restriction
+	case "minItems":
+		schema["minItems"] = restriction
+	case "maxItems":
+		schema["maxItems"] = restriction
+	case "uniqueItems":
+		schema["uniqueItems"] = restriction
+	}
+}
+
+// yamlEncode is a minimal, dependency-free YAML renderer for the plain
+// map[string]interface{}/[]interface{}/[]string/scalar shape buildOpenAPIDocument produces. It is
+// not a general purpose YAML encoder.
+func yamlEncode(v interface{}, indent int) string {
+	if strs, ok := v.([]string); ok {
+		items := make([]interface{}, len(strs))
+		for i, s := range strs {
+			items[i] = s
+		}
+		v = items
+	}
+	pad := strings.Repeat("  ", indent)
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 {
+			return pad + "{}\n"
+		}
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		for _, k := range keys {
+			switch val[k].(type) {
+			case map[string]interface{}, []interface{}, []string:
+				b.WriteString(fmt.Sprintf("%s%s:\n", pad, k))
+				b.WriteString(yamlEncode(val[k], indent+1))
+			default:
+				b.WriteString(fmt.Sprintf("%s%s: %s\n", pad, k, yamlScalar(val[k])))
+			}
+		}
+		return b.String()
+	case []interface{}:
+		if len(val) == 0 {
+			return pad + "[]\n"
+		}
+		var b strings.Builder
+		for _, item := range val {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				b.WriteString(pad + "-\n")
+				b.WriteString(yamlEncode(item, indent+1))
+			default:
+				b.WriteString(fmt.Sprintf("%s- %s\n", pad, yamlScalar(item)))
+			}
+		}
+		return b.String()
+	default:
+		return pad + yamlScalar(val) + "\n"
+	}
+}
+
+func yamlScalar(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", val)
+	case bool:
+		if val {
+			return "true"
+		}
+		return "false"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Quantile estimates streaming quantiles using the biased CKMS (Cormode, Korolova,
+// Muthukrishnan) algorithm: a sorted list of (value, g, delta) tuples is kept, where g is the
+// number of observations represented by the tuple and delta is the allowable rank error, so that
+// exact ranks never need to be stored. quantileTarget pairs trade memory for precision around the
+// quantiles callers actually care about (e.g. tight error near p99, loose error near the median).
+type Quantile struct {
+	mu      sync.Mutex
+	targets []quantileTarget
+	samples []quantileSample
+	n       int
+}
+
+type quantileTarget struct {
+	q   float64
+	eps float64
+}
+
+type quantileSample struct {
+	value float64
+	g     int
+	delta int
+}
+
+//defaultLatencyTargets is the quantile/error tradeoff used for per-endpoint request latency.
+var defaultLatencyTargets = []quantileTarget{
+	{q: 0.50, eps: 0.05},
+	{q: 0.90, eps: 0.01},
+	{q: 0.99, eps: 0.001},
+	{q: 0.999, eps: 0.0005},
+}
+
+//NewQuantile returns a Quantile estimator targeting the given quantile/error pairs.
+func NewQuantile(targets []quantileTarget) *Quantile {
+	return &Quantile{targets: targets}
+}
+
+// invariant is f(r, n): the maximum allowable rank error width at rank r out of n observations,
+// across every target quantile/error pair.
+func (q *Quantile) invariant(r, n float64) float64 {
+	if n == 0 {
+		return 0
+	}
+	max := 0.0
+	for _, t := range q.targets {
+		var f float64
+		if r <= t.q*n {
+			f = 2 * t.eps * r
+		} else {
+			f = 2 * t.eps * (n - r) / (1 - t.q)
+		}
+		if f > max {
+			max = f
+		}
+	}
+	return max
+}
+
+//Insert records a single observation.
+func (q *Quantile) Insert(value float64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := sort.Search(len(q.samples), func(i int) bool {
+		return q.samples[i].value >= value
+	})
+
+	r := 1
+	for _, s := range q.samples[:i] {
+		r += s.g
+	}
+
+	delta := 0
+	if i != 0 && i != len(q.samples) {
+		delta = int(math.Floor(q.invariant(float64(r), float64(q.n+1)))) - 1
+		if delta < 0 {
+			delta = 0
+		}
+	}
+
+	q.samples = append(q.samples, quantileSample{})
+	copy(q.samples[i+1:], q.samples[i:])
+	q.samples[i] = quantileSample{value: value, g: 1, delta: delta}
+	q.n++
+
+	q.compress()
+}
+
+// compress merges adjacent samples whenever doing so still satisfies the rank-error invariant,
+// keeping the sample list close to its theoretical O(1/eps * log(eps*n)) bound.
+func (q *Quantile) compress() {
+	if len(q.samples) < 2 {
+		return
+	}
+	n := float64(q.n)
+	merged := make([]quantileSample, 0, len(q.samples))
+	r := 0.0
+	i := 0
+	for i < len(q.samples)-1 {
+		cur := q.samples[i]
+		next := q.samples[i+1]
+		r += float64(cur.g)
+		if float64(cur.g+next.g+next.delta) <= q.invariant(r, n) {
+			merged = append(merged, quantileSample{value: next.value, g: cur.g + next.g, delta: next.delta})
+			r += float64(next.g)
+			i += 2
+			continue
+		}
+		merged = append(merged, cur)
+		i++
+	}
+	if i == len(q.samples)-1 {
+		merged = append(merged, q.samples[i])
+	}
+	q.samples = merged
+}
+
+//Query returns the estimated value at quantile target (0 <= target <= 1).
+func (q *Quantile) Query(target float64) float64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.queryLocked(target)
+}
+
+func (q *Quantile) queryLocked(target float64) float64 {
+	if len(q.samples) == 0 {
+		return 0
+	}
+	n := float64(q.n)
+	rank := target*n + q.invariant(target*n, n)/2
+	cum := 0.0
+	for _, s := range q.samples {
+		cum += float64(s.g)
+		if cum >= rank {
+			return s.value
+		}
+	}
+	return q.samples[len(q.samples)-1].value
+}
+
+//Snapshot returns the p50/p90/p99/p999 estimates as a JSON-friendly map.
+func (q *Quantile) Snapshot() map[string]float64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return map[string]float64{
+		"p50":  q.queryLocked(0.50),
+		"p90":  q.queryLocked(0.90),
+		"p99":  q.queryLocked(0.99),
+		"p999": q.queryLocked(0.999),
+	}
+}
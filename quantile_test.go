@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestQuantileWithinTargetError inserts a uniform 1..n stream (so the true value at rank r is
+// simply r) in random order, then checks every default latency target lands within a small
+// multiple of its configured eps * n - the biased CKMS merge step trades a bit of the strict
+// theoretical bound for a much smaller sample set, so a tight regression guard uses a slack
+// factor rather than the raw invariant.
+func TestQuantileWithinTargetError(t *testing.T) {
+	const n = 10000
+	const slack = 2.5
+	const minTolerance = 20.0
+
+	q := NewQuantile(defaultLatencyTargets)
+	rng := rand.New(rand.NewSource(42))
+	for _, v := range rng.Perm(n) {
+		q.Insert(float64(v + 1))
+	}
+
+	for _, target := range defaultLatencyTargets {
+		want := target.q * n
+		got := q.Query(target.q)
+
+		tolerance := target.eps * n * slack
+		if tolerance < minTolerance {
+			tolerance = minTolerance
+		}
+
+		if diff := math.Abs(got - want); diff > tolerance {
+			t.Errorf("q=%v: got %v, want ~%v (diff %v > tolerance %v)", target.q, got, want, diff, tolerance)
+		}
+	}
+}
+
+func TestQuantileMonotonic(t *testing.T) {
+	q := NewQuantile(defaultLatencyTargets)
+	rng := rand.New(rand.NewSource(7))
+	for _, v := range rng.Perm(5000) {
+		q.Insert(float64(v + 1))
+	}
+
+	prev := 0.0
+	for _, target := range []float64{0.50, 0.90, 0.99, 0.999} {
+		got := q.Query(target)
+		if got < prev {
+			t.Errorf("q=%v: got %v, which is less than the estimate %v for a lower quantile", target, got, prev)
+		}
+		prev = got
+	}
+}
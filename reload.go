@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// Reloader holds the currently active *http.ServeMux behind an atomic pointer so that a config
+// reload - triggered by SIGHUP or POST /admin/reload - can build a brand new mux from scratch and
+// swap it in without ever taking requests down or serving a half-built mux.
+type Reloader struct {
+	confFile string
+	mux      atomic.Pointer[http.ServeMux]
+	routes   atomic.Pointer[map[string]RouteSummary]
+}
+
+// NewReloader returns a Reloader that (re)reads its config from confFile on every Reload.
+func NewReloader(confFile string) *Reloader {
+	return &Reloader{confFile: confFile}
+}
+
+func (rl *Reloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mux := rl.mux.Load()
+	if mux == nil {
+		http.Error(w, "server not ready", http.StatusServiceUnavailable)
+		return
+	}
+	mux.ServeHTTP(w, r)
+}
+
+// RouteSummary identifies one route for the purposes of diffing two reloads.
+type RouteSummary struct {
+	Path      string `json:"path"`
+	Method    string `json:"method"`
+	Transport string `json:"transport"`
+}
+
+// ReloadResult is returned from Reload and by POST /admin/reload, describing what a reload would
+// change (DryRun true) or did change (DryRun false).
+type ReloadResult struct {
+	DryRun  bool           `json:"dry_run"`
+	Added   []RouteSummary `json:"added"`
+	Removed []RouteSummary `json:"removed"`
+	Changed []RouteSummary `json:"changed"`
+}
+
+// reservedRoutes are registered on every mux before any protocol route, so a config that reuses
+// one of these paths must be rejected rather than reaching mux.HandleFunc, which panics on a
+// duplicate pattern.
+var reservedRoutes = map[string]bool{
+	"/statistics":   true,
+	"/admin/reload": true,
+	"/openapi.json": true,
+	"/openapi.yaml": true,
+}
+
+// Reload re-parses confFile, regenerates every protocol's validators, and builds a fresh mux.
+// When dryRun is false and the config is valid, the new mux is swapped in atomically; when dryRun
+// is true, or the config fails to parse/validate, nothing about the running server changes.
+func (rl *Reloader) Reload(dryRun bool) (*ReloadResult, error) {
+	conf, err := loadConfig(rl.confFile)
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/statistics", statisticHandler)
+	mux.HandleFunc("/admin/reload", rl.reloadHandler)
+	mux.HandleFunc("/openapi.json", openAPIJSONHandler(conf))
+	mux.HandleFunc("/openapi.yaml", openAPIYAMLHandler(conf))
+
+	newRoutes := map[string]RouteSummary{}
+	for _, protocol := range conf.Protocols {
+		if reservedRoutes[protocol.Path] {
+			return nil, fmt.Errorf("path %s collides with a reserved route", protocol.Path)
+		}
+		if _, dup := newRoutes[protocol.Path]; dup {
+			return nil, fmt.Errorf("duplicate path %s", protocol.Path)
+		}
+
+		validFuncs, err := generateValidFuncs(protocol.Args)
+		if err != nil {
+			return nil, fmt.Errorf("protocol %s: %w", protocol.Path, err)
+		}
+		protocol.validFuncs = validFuncs
+
+		protocol.crossFieldRules, err = buildCrossFieldRules(protocol.Args)
+		if err != nil {
+			return nil, fmt.Errorf("protocol %s: %w", protocol.Path, err)
+		}
+
+		newRoutes[protocol.Path] = RouteSummary{Path: protocol.Path, Method: protocol.Method, Transport: protocol.Transport}
+
+		if protocol.servesHTTP() {
+			if !dryRun {
+				log.Println("add", protocol.Transport, protocol.Method, protocol.Path)
+			}
+			mux.HandleFunc(protocol.Path, newHandleFunc(protocol.Method, protocol.Args, validFuncs, protocol.crossFieldRules))
+		}
+	}
+
+	result := diffRoutes(rl.currentRoutes(), newRoutes)
+	result.DryRun = dryRun
+
+	if !dryRun {
+		rl.mux.Store(mux)
+		rl.routes.Store(&newRoutes)
+	}
+	return result, nil
+}
+
+func (rl *Reloader) currentRoutes() map[string]RouteSummary {
+	if p := rl.routes.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+func diffRoutes(old, latest map[string]RouteSummary) *ReloadResult {
+	result := &ReloadResult{}
+	for path, route := range latest {
+		prev, existed := old[path]
+		switch {
+		case !existed:
+			result.Added = append(result.Added, route)
+		case prev != route:
+			result.Changed = append(result.Changed, route)
+		}
+	}
+	for path, route := range old {
+		if _, stillExists := latest[path]; !stillExists {
+			result.Removed = append(result.Removed, route)
+		}
+	}
+	return result
+}
+
+func (rl *Reloader) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		m{
+			"code": Code_Err_Request,
+			"msg":  fmt.Sprintf("mothod '%s' is not supported", r.Method),
+		}.Write(w)
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	result, err := rl.Reload(dryRun)
+	if err != nil {
+		m{
+			"code": Code_Err_Request,
+			"msg":  err.Error(),
+		}.Write(w)
+		return
+	}
+	m{
+		"code":   Code_OK,
+		"reload": result,
+	}.Write(w)
+}
+
+// watchSIGHUP triggers a non-dry-run Reload every time the process receives SIGHUP.
+func watchSIGHUP(rl *Reloader) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	go func() {
+		for range sigCh {
+			log.Println("SIGHUP received, reloading config")
+			result, err := rl.Reload(false)
+			if err != nil {
+				log.Println("reload failed", err.Error())
+				continue
+			}
+			log.Printf("reload done: +%d -%d ~%d\n", len(result.Added), len(result.Removed), len(result.Changed))
+		}
+	}()
+}
+
+func loadConfig(confFile string) (*Config, error) {
+	f, err := os.OpenFile(confFile, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("open config file: %w", err)
+	}
+	defer f.Close()
+
+	var conf Config
+	decoder := json.NewDecoder(f)
+	decoder.UseNumber()
+	if err := decoder.Decode(&conf); err != nil {
+		return nil, fmt.Errorf("decode config file: %w", err)
+	}
+	return &conf, nil
+}
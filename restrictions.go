@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+)
+
+// RestrictionFactory builds a validFunc for a single restriction value, given the ArgType of the
+// arg the restriction is attached to. Factories are looked up by restriction name at config-load
+// time, so a bad or unknown restriction is caught before the server ever starts serving traffic.
+//
+// A factory may return a nil validFunc with a nil error to signal that the restriction is handled
+// elsewhere (see "requires"/"excludes", which are cross-argument and evaluated by
+// buildCrossFieldRules instead of per-arg).
+type RestrictionFactory func(argType ArgType, restriction interface{}) (validFunc, error)
+
+// RestrictionRegistry holds the restriction factories known at config-load time. Built-in
+// restrictions are registered on DefaultRegistry; third parties can add their own with Register.
+type RestrictionRegistry struct {
+	factories map[string]RestrictionFactory
+}
+
+//NewRestrictionRegistry returns an empty registry with none of the built-ins registered.
+func NewRestrictionRegistry() *RestrictionRegistry {
+	return &RestrictionRegistry{factories: map[string]RestrictionFactory{}}
+}
+
+//Register adds or replaces the factory for a restriction name.
+func (r *RestrictionRegistry) Register(name string, factory RestrictionFactory) {
+	r.factories[name] = factory
+}
+
+//Lookup returns the factory registered for name, if any.
+func (r *RestrictionRegistry) Lookup(name string) (RestrictionFactory, bool) {
+	factory, ok := r.factories[name]
+	return factory, ok
+}
+
+//DefaultRegistry is the registry generateValidFunc consults when building arg validation.
+var DefaultRegistry = NewRestrictionRegistry()
+
+func init() {
+	DefaultRegistry.Register("length", restrictionLength)
+	DefaultRegistry.Register("range", restrictionRange)
+	DefaultRegistry.Register("regex", restrictionRegex)
+	DefaultRegistry.Register("enum", restrictionEnum)
+	DefaultRegistry.Register("format", restrictionFormat)
+	DefaultRegistry.Register("requires", restrictionRequires)
+	DefaultRegistry.Register("excludes", restrictionExcludes)
+}
+
+func restrictionLength(argType ArgType, restriction interface{}) (validFunc, error) {
+	if argType != String {
+		return nil, fmt.Errorf("length only applies to string args")
+	}
+	lengthFunc, err := generateStringLengthValidFunc(restriction)
+	if err != nil {
+		return nil, err
+	}
+	if lengthFunc == nil {
+		return nil, fmt.Errorf("length restriction needs a min and/or a max")
+	}
+	return newStringTypeValidFunc(lengthFunc), nil
+}
+
+func restrictionRange(argType ArgType, restriction interface{}) (validFunc, error) {
+	if argType != Int {
+		return nil, fmt.Errorf("range only applies to int args")
+	}
+	rangeFunc, err := generateIntRangeValidFunc(restriction)
+	if err != nil {
+		return nil, err
+	}
+	if rangeFunc == nil {
+		return nil, fmt.Errorf("range restriction needs a min and/or a max")
+	}
+	return newIntTypeValidFunc(rangeFunc), nil
+}
+
+func restrictionRegex(argType ArgType, restriction interface{}) (validFunc, error) {
+	if argType != String {
+		return nil, fmt.Errorf("regex only applies to string args")
+	}
+	pattern, ok := restriction.(string)
+	if !ok {
+		return nil, fmt.Errorf("regex restriction must be a pattern string")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("compile pattern %q: %w", pattern, err)
+	}
+	return newStringTypeValidFunc(func(s string) error {
+		if !re.MatchString(s) {
+			return fmt.Errorf("does not match pattern %q", pattern)
+		}
+		return nil
+	}), nil
+}
+
+func restrictionEnum(argType ArgType, restriction interface{}) (validFunc, error) {
+	values, ok := restriction.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("enum restriction must be a list of allowed values")
+	}
+	switch argType {
+	case String:
+		allowed := map[string]bool{}
+		for _, v := range values {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("enum value %v is not a string", v)
+			}
+			allowed[s] = true
+		}
+		return newStringTypeValidFunc(func(s string) error {
+			if !allowed[s] {
+				return fmt.Errorf("%q is not one of %v", s, values)
+			}
+			return nil
+		}), nil
+	case Int:
+		allowed := map[int64]bool{}
+		for _, v := range values {
+			n, ok := v.(json.Number)
+			if !ok {
+				return nil, fmt.Errorf("enum value %v is not a number", v)
+			}
+			i, err := n.Int64()
+			if err != nil {
+				return nil, err
+			}
+			allowed[i] = true
+		}
+		return newIntTypeValidFunc(func(i int64) error {
+			if !allowed[i] {
+				return fmt.Errorf("%d is not one of %v", i, values)
+			}
+			return nil
+		}), nil
+	default:
+		return nil, fmt.Errorf("enum only applies to string or int args")
+	}
+}
+
+//formatCheckers backs the "format" restriction, keyed by format name.
+var formatCheckers = map[string]stringValidFunc{
+	"email": func(s string) error {
+		if _, err := mail.ParseAddress(s); err != nil {
+			return fmt.Errorf("not a valid email: %s", err)
+		}
+		return nil
+	},
+	"uuid": func(s string) error {
+		if !uuidPattern.MatchString(s) {
+			return fmt.Errorf("not a valid uuid")
+		}
+		return nil
+	},
+	"url": func(s string) error {
+		u, err := url.ParseRequestURI(s)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("not a valid url")
+		}
+		return nil
+	},
+	"ipv4": func(s string) error {
+		ip := net.ParseIP(s)
+		if ip == nil || ip.To4() == nil {
+			return fmt.Errorf("not a valid ipv4 address")
+		}
+		return nil
+	},
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func restrictionFormat(argType ArgType, restriction interface{}) (validFunc, error) {
+	if argType != String {
+		return nil, fmt.Errorf("format only applies to string args")
+	}
+	name, ok := restriction.(string)
+	if !ok {
+		return nil, fmt.Errorf("format restriction must name a format")
+	}
+	checker, ok := formatCheckers[name]
+	if !ok {
+		return nil, fmt.Errorf("format %q is not supported", name)
+	}
+	return newStringTypeValidFunc(checker), nil
+}
+
+// restrictionRequires and restrictionExcludes are registered so that config load recognises the
+// names, but the actual checks need the whole request, not just this arg's value - see
+// buildCrossFieldRules, which reads the "requires"/"excludes" restrictions directly.
+func restrictionRequires(argType ArgType, restriction interface{}) (validFunc, error) {
+	return nil, nil
+}
+
+func restrictionExcludes(argType ArgType, restriction interface{}) (validFunc, error) {
+	return nil, nil
+}
+
+//CrossFieldRule is a validation check that needs more than one arg's value to evaluate, such as
+//"requires"/"excludes". Arg names the arg the rule was declared on, so failures can be attributed
+//to it in the invalid map the same way a per-arg validFunc failure is.
+type CrossFieldRule struct {
+	Arg   string
+	Check func(data map[string]interface{}) error
+}
+
+//buildCrossFieldRules scans every arg's Restrictions for "requires"/"excludes" and turns them into
+//CrossFieldRules, evaluated by validateArgs once per-arg validation has completed.
+func buildCrossFieldRules(args []*Arg) ([]CrossFieldRule, error) {
+	var rules []CrossFieldRule
+	for _, arg := range args {
+		argName := arg.Name
+		if restriction, ok := arg.Restrictions["requires"]; ok {
+			others, err := restrictionArgNames(restriction)
+			if err != nil {
+				return nil, fmt.Errorf("arg %s: restriction \"requires\": %w", argName, err)
+			}
+			rules = append(rules, CrossFieldRule{Arg: argName, Check: func(data map[string]interface{}) error {
+				if _, present := data[argName]; !present {
+					return nil
+				}
+				for _, other := range others {
+					if _, ok := data[other]; !ok {
+						return fmt.Errorf("requires %q", other)
+					}
+				}
+				return nil
+			}})
+		}
+		if restriction, ok := arg.Restrictions["excludes"]; ok {
+			others, err := restrictionArgNames(restriction)
+			if err != nil {
+				return nil, fmt.Errorf("arg %s: restriction \"excludes\": %w", argName, err)
+			}
+			rules = append(rules, CrossFieldRule{Arg: argName, Check: func(data map[string]interface{}) error {
+				if _, present := data[argName]; !present {
+					return nil
+				}
+				for _, other := range others {
+					if _, ok := data[other]; ok {
+						return fmt.Errorf("excludes %q", other)
+					}
+				}
+				return nil
+			}})
+		}
+	}
+	return rules, nil
+}
+
+func restrictionArgNames(restriction interface{}) ([]string, error) {
+	list, ok := restriction.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list of arg names")
+	}
+	names := make([]string, 0, len(list))
+	for _, v := range list {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a list of arg names")
+		}
+		names = append(names, s)
+	}
+	return names, nil
+}
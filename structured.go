@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+//FieldError is a single validation failure, located by a JSON-Pointer (RFC 6901) path so a client
+//can pinpoint the offending field inside a deeply nested Object/Array payload.
+type FieldError struct {
+	Pointer string
+	Message string
+}
+
+// compileArg recursively compiles the validFunc for arg and, for Object/Array args, every
+// descendant Arg reachable through Properties/Items. It also rejects restriction names that
+// don't apply to Object/Array, matching the "fail config load loudly" behaviour the rest of
+// restriction handling already has.
+func compileArg(arg *Arg) error {
+	switch arg.Type {
+	case Object:
+		for name := range arg.Restrictions {
+			if name != "additionalProperties" && name != "required" {
+				return fmt.Errorf("restriction %q does not apply to object args", name)
+			}
+		}
+		for _, prop := range arg.Properties {
+			if err := compileArg(prop); err != nil {
+				return fmt.Errorf("%s: %w", prop.Name, err)
+			}
+		}
+		return nil
+	case Array:
+		for name := range arg.Restrictions {
+			if name != "minItems" && name != "maxItems" && name != "uniqueItems" {
+				return fmt.Errorf("restriction %q does not apply to array args", name)
+			}
+		}
+		if arg.Items == nil {
+			return fmt.Errorf("array arg needs Items")
+		}
+		if err := compileArg(arg.Items); err != nil {
+			return fmt.Errorf("[]: %w", err)
+		}
+		return nil
+	default:
+		validFunc, err := generateValidFunc(arg)
+		if err != nil {
+			return err
+		}
+		arg.validFunc = validFunc
+		return nil
+	}
+}
+
+// validateNested validates v against arg at the given JSON-Pointer location, recursing into
+// Object/Array structure and returning every failure found rather than stopping at the first.
+func validateNested(arg *Arg, v interface{}, pointer string) []FieldError {
+	switch arg.Type {
+	case Object:
+		return validateObjectArg(arg, v, pointer)
+	case Array:
+		return validateArrayArg(arg, v, pointer)
+	default:
+		if arg.validFunc == nil {
+			return nil
+		}
+		if err := arg.validFunc(v); err != nil {
+			return []FieldError{{Pointer: pointer, Message: err.Error()}}
+		}
+		return nil
+	}
+}
+
+func validateObjectArg(arg *Arg, v interface{}, pointer string) []FieldError {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return []FieldError{{Pointer: pointer, Message: "not an object"}}
+	}
+
+	var errs []FieldError
+	seen := make(map[string]bool, len(arg.Properties))
+	for _, prop := range arg.Properties {
+		seen[prop.Name] = true
+		propPointer := pointer + "/" + prop.Name
+
+		pv, present := obj[prop.Name]
+		if !present {
+			if prop.Required {
+				errs = append(errs, FieldError{Pointer: propPointer, Message: "<missed>"})
+			}
+			continue
+		}
+		errs = append(errs, validateNested(prop, pv, propPointer)...)
+	}
+
+	if allowed, ok := arg.Restrictions["additionalProperties"].(bool); ok && !allowed {
+		for key := range obj {
+			if !seen[key] {
+				errs = append(errs, FieldError{Pointer: pointer + "/" + key, Message: "additional property is not allowed"})
+			}
+		}
+	}
+
+	if required, ok := arg.Restrictions["required"].([]interface{}); ok {
+		for _, name := range required {
+			propName, ok := name.(string)
+			if !ok {
+				continue
+			}
+			if _, present := obj[propName]; !present {
+				errs = append(errs, FieldError{Pointer: pointer + "/" + propName, Message: "<missed>"})
+			}
+		}
+	}
+
+	return errs
+}
+
+func validateArrayArg(arg *Arg, v interface{}, pointer string) []FieldError {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return []FieldError{{Pointer: pointer, Message: "not an array"}}
+	}
+
+	var errs []FieldError
+	if n, ok := restrictionInt(arg.Restrictions["minItems"]); ok && int64(len(arr)) < n {
+		errs = append(errs, FieldError{Pointer: pointer, Message: fmt.Sprintf("minItems: %d, current: %d", n, len(arr))})
+	}
+	if n, ok := restrictionInt(arg.Restrictions["maxItems"]); ok && int64(len(arr)) > n {
+		errs = append(errs, FieldError{Pointer: pointer, Message: fmt.Sprintf("maxItems: %d, current: %d", n, len(arr))})
+	}
+	if unique, ok := arg.Restrictions["uniqueItems"].(bool); ok && unique {
+		seen := make(map[string]bool, len(arr))
+		for i, item := range arr {
+			key := fmt.Sprintf("%v", item)
+			if seen[key] {
+				errs = append(errs, FieldError{Pointer: fmt.Sprintf("%s/%d", pointer, i), Message: "duplicate item, uniqueItems is set"})
+			}
+			seen[key] = true
+		}
+	}
+
+	for i, item := range arr {
+		errs = append(errs, validateNested(arg.Items, item, fmt.Sprintf("%s/%d", pointer, i))...)
+	}
+
+	return errs
+}
+
+func restrictionInt(restriction interface{}) (int64, bool) {
+	n, ok := restriction.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	if err != nil {
+		return 0, false
+	}
+	return i, true
+}